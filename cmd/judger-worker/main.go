@@ -0,0 +1,46 @@
+// Command judger-worker is the long-lived helper process Worker spawns and
+// talks to over the socketpair it inherits as fd 3. It reads one
+// length-prefixed gob-encoded Config per request, runs it through
+// judger.RunE, and writes back a length-prefixed gob-encoded Result, until
+// the connection is closed.
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/suntt2019/Judger"
+)
+
+func main() {
+	conn, err := net.FileConn(os.NewFile(3, "judger-worker-conn"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "judger-worker: fd 3 is not a usable connection: %v\n", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	for {
+		payload, err := judger.ReadFrame(conn)
+		if err != nil {
+			return // parent closed the connection, or we are being torn down
+		}
+		config, err := judger.DecodeConfig(payload)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "judger-worker: decoding request: %v\n", err)
+			return
+		}
+
+		result, _ := judger.RunE(config)
+
+		reply, err := judger.EncodeResult(result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "judger-worker: encoding response: %v\n", err)
+			return
+		}
+		if err := judger.WriteFrame(conn, reply); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package judger
+
+/*
+#include "seccomp_rules.h"
+*/
+import "C"
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// pidRegistry tracks the pids of currently-running sandboxed children.
+// RunContext itself clears a pid's entry the instant C.run returns — the
+// only point that reliably knows the child has been reaped — so the
+// cancellation watcher's read of the registry reflects whether the run is
+// still live as tightly as a plain map can, narrowing (though, being pid-
+// based rather than pidfd-based, not fully eliminating) the window where a
+// reused pid could be signalled instead of the original process.
+var pidRegistry sync.Map // int(pid) -> struct{}
+
+// RunContext behaves like RunE, but if ctx is done before the sandboxed
+// process finishes on its own, it sends SIGKILL to that process, letting a
+// caller (e.g. an HTTP handler whose client disconnected) cancel a stuck
+// submission. If ctx has a deadline sooner than config.MaxRealTime (or
+// MaxRealTime sets no cap), it is additionally applied as a wall clock cap.
+func RunContext(ctx context.Context, config Config) (Result, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := int(time.Until(deadline).Milliseconds())
+		if config.MaxRealTime <= 0 || remaining < config.MaxRealTime {
+			config.MaxRealTime = remaining
+		}
+	}
+
+	var cResult C.struct_result
+	cConfig := config.convertToCStruct()
+	var cPid C.pid_t
+	cConfig.pid_out = &cPid // written by run() right after fork, while it is still blocked in waitpid
+
+	finished := make(chan struct{})
+	var killed int32
+	go watchForCancel(ctx, &cPid, finished, &killed)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	var peakRSS int32
+	if config.SampleIntervalMs > 0 {
+		onSample := config.OnSample
+		if onSample == nil {
+			onSample = func(Sample) {}
+		}
+		wg.Add(1)
+		go sampleWhileRunning(config.SampleIntervalMs, &cPid, onSample, &peakRSS, stop, &wg)
+	}
+
+	C.run(&cConfig, &cResult)
+	// C.run has just unblocked, meaning the child has been reaped and cPid
+	// will never be signalled again; unregister it before telling
+	// watchForCancel the run is finished, so a concurrent cancellation
+	// cannot observe a pid that is both "finished" and "still registered".
+	if pid := int(atomic.LoadInt32((*int32)(unsafe.Pointer(&cPid)))); pid != 0 {
+		pidRegistry.Delete(pid)
+	}
+	close(finished)
+	close(stop)
+	wg.Wait()
+
+	var result Result
+	result.convertFromCStruct(cResult)
+	result.PeakRSS = peakRSS
+	freeCConfig(&cConfig)
+
+	if atomic.LoadInt32(&killed) == 1 {
+		return result, ctx.Err()
+	}
+	return result, errorFromResult(result)
+}
+
+// watchForCancel waits for the sandboxed pid to become known, registers
+// it, then waits for either the run to finish on its own or ctx to be
+// cancelled, in which case it signals the pid's process group — but only
+// if RunContext has not already unregistered it (RunContext, not this
+// goroutine, owns the Delete, since only RunContext knows the instant
+// C.run returns). run() puts the child in its own process group
+// (setpgid(0, 0)) before execve, so killing -pid also reaches anything
+// the judged program itself forked.
+func watchForCancel(ctx context.Context, cPid *C.pid_t, finished <-chan struct{}, killed *int32) {
+	pid := waitForPid(cPid, finished)
+	if pid == 0 {
+		return
+	}
+	pidRegistry.Store(pid, struct{}{})
+
+	select {
+	case <-finished:
+		return
+	default:
+	}
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		if _, stillRunning := pidRegistry.Load(pid); stillRunning {
+			atomic.StoreInt32(killed, 1)
+			syscall.Kill(-pid, syscall.SIGKILL)
+		}
+	}
+}
+
+// waitForPid polls cPid, which run() writes right after fork, until it is
+// non-zero or finished is closed (the process exited before a pid was
+// ever observed, handled defensively even though it should not happen).
+func waitForPid(cPid *C.pid_t, finished <-chan struct{}) int {
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if pid := int(atomic.LoadInt32((*int32)(unsafe.Pointer(cPid)))); pid != 0 {
+			return pid
+		}
+		select {
+		case <-finished:
+			return 0
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package judger
+
+import "fmt"
+
+// Verdict is the judger's verdict on the judged program's own behaviour
+// (the value previously carried as the raw int Result.Result).
+type Verdict int
+
+const (
+	Success Verdict = iota
+	CPUTimeLimitExceeded
+	RealTimeLimitExceeded
+	MemoryLimitExceeded
+	RuntimeError
+	SystemError
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Success:
+		return "SUCCESS"
+	case CPUTimeLimitExceeded:
+		return "CPU_TIME_LIMIT_EXCEEDED"
+	case RealTimeLimitExceeded:
+		return "REAL_TIME_LIMIT_EXCEEDED"
+	case MemoryLimitExceeded:
+		return "MEMORY_LIMIT_EXCEEDED"
+	case RuntimeError:
+		return "RUNTIME_ERROR"
+	case SystemError:
+		return "SYSTEM_ERROR"
+	default:
+		return fmt.Sprintf("Verdict(%d)", int(v))
+	}
+}
+
+// JudgerError is the judger's internal error code (the value previously
+// carried as the raw int Result.Error): args validation errors and
+// failures setting up the sandbox, as distinct from Verdict, which
+// describes how the judged program itself behaved. JudgerError implements
+// error, so it can be returned directly from Run and matched with
+// errors.Is(err, judger.ErrRootRequired).
+type JudgerError int
+
+const (
+	ErrNone              JudgerError = 0
+	ErrInvalidConfig     JudgerError = -1
+	ErrForkFailed        JudgerError = -2
+	ErrPthreadFailed     JudgerError = -3
+	ErrWaitFailed        JudgerError = -4
+	ErrRootRequired      JudgerError = -5
+	ErrLoadSeccompFailed JudgerError = -6
+	ErrSetrlimitFailed   JudgerError = -7
+	ErrDup2Failed        JudgerError = -8
+	ErrSetuidFailed      JudgerError = -9
+	ErrExecveFailed      JudgerError = -10
+	ErrSpjError          JudgerError = -11
+)
+
+func (e JudgerError) String() string {
+	switch e {
+	case ErrNone:
+		return "SUCCESS"
+	case ErrInvalidConfig:
+		return "INVALID_CONFIG"
+	case ErrForkFailed:
+		return "FORK_FAILED"
+	case ErrPthreadFailed:
+		return "PTHREAD_FAILED"
+	case ErrWaitFailed:
+		return "WAIT_FAILED"
+	case ErrRootRequired:
+		return "ROOT_REQUIRED"
+	case ErrLoadSeccompFailed:
+		return "LOAD_SECCOMP_FAILED"
+	case ErrSetrlimitFailed:
+		return "SETRLIMIT_FAILED"
+	case ErrDup2Failed:
+		return "DUP2_FAILED"
+	case ErrSetuidFailed:
+		return "SETUID_FAILED"
+	case ErrExecveFailed:
+		return "EXECVE_FAILED"
+	case ErrSpjError:
+		return "SPJ_ERROR"
+	default:
+		return fmt.Sprintf("JudgerError(%d)", int(e))
+	}
+}
+
+// Error implements the error interface, so a JudgerError can be returned
+// directly wherever an error is expected.
+func (e JudgerError) Error() string {
+	return e.String()
+}
+
+// errorFromResult returns result.Error as an error, or nil when it is
+// ErrNone. Run, RunContext and Worker.Run all use this so
+// errors.Is(err, judger.ErrRootRequired) works regardless of which of them
+// a caller used.
+func errorFromResult(result Result) error {
+	if result.Error == ErrNone {
+		return nil
+	}
+	return result.Error
+}
@@ -0,0 +1,57 @@
+package judger
+
+import "testing"
+
+func TestVerdictString(t *testing.T) {
+	cases := []struct {
+		v    Verdict
+		want string
+	}{
+		{Success, "SUCCESS"},
+		{CPUTimeLimitExceeded, "CPU_TIME_LIMIT_EXCEEDED"},
+		{RealTimeLimitExceeded, "REAL_TIME_LIMIT_EXCEEDED"},
+		{MemoryLimitExceeded, "MEMORY_LIMIT_EXCEEDED"},
+		{RuntimeError, "RUNTIME_ERROR"},
+		{SystemError, "SYSTEM_ERROR"},
+		{Verdict(99), "Verdict(99)"},
+	}
+	for _, c := range cases {
+		if got := c.v.String(); got != c.want {
+			t.Errorf("Verdict(%d).String() = %q, want %q", int(c.v), got, c.want)
+		}
+	}
+}
+
+func TestJudgerErrorString(t *testing.T) {
+	cases := []struct {
+		e    JudgerError
+		want string
+	}{
+		{ErrNone, "SUCCESS"},
+		{ErrInvalidConfig, "INVALID_CONFIG"},
+		{ErrRootRequired, "ROOT_REQUIRED"},
+		{ErrSpjError, "SPJ_ERROR"},
+		{JudgerError(-99), "JudgerError(-99)"},
+	}
+	for _, c := range cases {
+		if got := c.e.String(); got != c.want {
+			t.Errorf("JudgerError(%d).String() = %q, want %q", int(c.e), got, c.want)
+		}
+	}
+}
+
+func TestJudgerErrorImplementsError(t *testing.T) {
+	var err error = ErrRootRequired
+	if err.Error() != "ROOT_REQUIRED" {
+		t.Errorf("ErrRootRequired.Error() = %q, want %q", err.Error(), "ROOT_REQUIRED")
+	}
+}
+
+func TestErrorFromResult(t *testing.T) {
+	if err := errorFromResult(Result{Error: ErrNone}); err != nil {
+		t.Errorf("errorFromResult with ErrNone = %v, want nil", err)
+	}
+	if err := errorFromResult(Result{Error: ErrRootRequired}); err != ErrRootRequired {
+		t.Errorf("errorFromResult with ErrRootRequired = %v, want %v", err, ErrRootRequired)
+	}
+}
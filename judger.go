@@ -2,17 +2,44 @@ package judger
 
 /*
 #cgo pkg-config: libseccomp
+#cgo LDFLAGS: -lpthread
 #include "seccomp_rules.h"
 #include "stdlib.h"
 */
 import "C"
-import "unsafe"
+import (
+	"sync"
+	"unsafe"
+)
 
 const (
 	ArgsMaxNumber = 256
 	EnvMaxNumber  = 256
 )
 
+// ResourceBackend selects how MaxMemory, MaxCPUTime and MaxProcessNumber
+// are enforced on the child process. MaxOutputSize is enforced with
+// setrlimit(RLIMIT_FSIZE) under either backend.
+type ResourceBackend int
+
+const (
+	// BackendRlimit enforces limits with setrlimit, as the judger has always done.
+	BackendRlimit ResourceBackend = iota
+	// BackendCgroupV2 enforces limits by moving the child into a transient
+	// cgroup v2 (memory.max, memory.swap.max, cpu.max, pids.max) before
+	// execve instead of applying the memory, CPU time and process number
+	// rlimits; MaxStack and MaxOutputSize are still applied with setrlimit.
+	// cpu.max caps the rate at which the cgroup uses CPU, not its total CPU
+	// time: a run that goes over MaxCPUTime is not killed for it, and is
+	// reported as CPUTimeLimitExceeded once it exits. The cgroup is killed via
+	// memory.oom.group on MLE, and Memory is read back from memory.peak,
+	// which does not suffer from the setrlimit(maxrss) crash issues noted
+	// on MemoryLimitCheckOnly below. If cgroup v2 is not mounted, or the
+	// transient cgroup cannot be created, run() silently falls back to
+	// BackendRlimit for that run.
+	BackendCgroupV2
+)
+
 /*
 Config is a struct used to record the running configuration.
 
@@ -33,6 +60,12 @@ LogPath: judger log path
 SeccompRuleName(string or NULL): seccomp rules used to limit process system calls. Name is used to call corresponding functions.
 Uid: user to run this process
 Gid: user group this process belongs to
+ResourceBackend: BackendRlimit (default) or BackendCgroupV2, see ResourceBackend
+CgroupParentPath: parent cgroup v2 directory under which a transient per-run cgroup is created, ignored unless ResourceBackend is BackendCgroupV2
+SeccompFilter: a pre-compiled BPF program (the raw array of struct sock_filter instructions a struct sock_fprog points to) to install directly instead of a named rule, takes precedence over SeccompRuleName
+SeccompDefaultAction: the SECCOMP_RET_* action returned for syscalls SeccompFilter does not match, ignored unless SeccompFilter is set
+SampleIntervalMs: if greater than 0, poll the running process' /proc entries at this interval and invoke OnSample with a Sample, 0 disables sampling
+OnSample: callback invoked from a background goroutine for each Sample gathered while the process runs, ignored unless SampleIntervalMs is greater than 0
 */
 type Config struct {
 	MaxCPUTime           int
@@ -52,6 +85,12 @@ type Config struct {
 	SeccompRuleName      string
 	Uid                  uint32
 	Gid                  uint32
+	ResourceBackend      ResourceBackend
+	CgroupParentPath     string
+	SeccompFilter        []byte
+	SeccompDefaultAction int
+	SampleIntervalMs     int
+	OnSample             func(Sample)
 }
 
 /*
@@ -59,38 +98,25 @@ Result is a struct used to record the running result.
 
 CPUTime: cpu time the process has used
 RealTime: actual running time of the process
-Memory: max value of memory used by the process
+Memory: max value of memory used by the process. Under BackendCgroupV2 this is read from memory.peak instead of rusage
+PeakRSS: highest VmHWM/VmPeak observed while sampling via /proc, only populated when SampleIntervalMs is set; more accurate than Memory for short-lived processes that free memory before exit
 Signal: signal number
 ExitCode: process's exit code
-Result: judger result.
-SUCCESS = 0
-CPU_TIME_LIMIT_EXCEEDED=1
-REAL_TIME_LIMIT_EXCEEDED=2
-MEMORY_LIMIT_EXCEEDED=3
-RUNTIME_ERROR=4
-SYSTEM_ERROR=5
-Error: args validation error or judger internal error.
-SUCCESS = 0
-INVALID_CONFIG = -1
-FORK_FAILED = -2
-PTHREAD_FAILED = -3
-WAIT_FAILED = -4
-ROOT_REQUIRED = -5
-LOAD_SECCOMP_FAILED = -6
-SETRLIMIT_FAILED = -7
-DUP2_FAILED = -8
-SETUID_FAILED = -9
-EXECVE_FAILED = -10
-SPJ_ERROR = -11
+Result: judger verdict, see Verdict
+Error: args validation error or judger internal error, see JudgerError
+ResultCode, ErrorCode: deprecated int-valued mirrors of Result and Error, kept for one release so existing callers that read the raw codes keep compiling; new code should use Result/Error directly
 */
 type Result struct {
-	CPUTime  int
-	RealTime int
-	Memory   int32
-	Signal   int
-	ExitCode int
-	Result   int
-	Error    int
+	CPUTime    int
+	RealTime   int
+	Memory     int32
+	PeakRSS    int32
+	Signal     int
+	ExitCode   int
+	Result     Verdict
+	Error      JudgerError
+	ResultCode int
+	ErrorCode  int
 }
 
 func (c Config) convertToCStruct() (cc C.struct_config) {
@@ -115,6 +141,13 @@ func (c Config) convertToCStruct() (cc C.struct_config) {
 	cc.seccomp_rule_name = C.CString(c.SeccompRuleName)
 	cc.uid = C.uint(c.Uid)
 	cc.gid = C.uint(c.Gid)
+	cc.resource_backend = C.int(c.ResourceBackend)
+	cc.cgroup_parent_path = C.CString(c.CgroupParentPath)
+	if len(c.SeccompFilter) > 0 {
+		cc.seccomp_filter = (*C.uchar)(C.CBytes(c.SeccompFilter))
+		cc.seccomp_filter_len = C.uint(len(c.SeccompFilter))
+	}
+	cc.seccomp_default_action = C.uint(c.SeccompDefaultAction)
 	return
 }
 
@@ -124,33 +157,71 @@ func (r *Result) convertFromCStruct(cr C.struct_result) {
 	r.Memory = int32(cr.memory)
 	r.Signal = int(cr.signal)
 	r.ExitCode = int(cr.exit_code)
-	r.Result = int(cr.result)
-	r.Error = int(cr.error)
+	r.Result = Verdict(cr.result)
+	r.Error = JudgerError(cr.error)
+	r.ResultCode = int(cr.result)
+	r.ErrorCode = int(cr.error)
+}
+
+// Run runs the program in the sandbox according to the config and returns
+// the result and error exactly as RunE does, so a JudgerError can be told
+// apart from a merely unsuccessful Verdict.
+func Run(config Config) (Result, error) {
+	return RunE(config)
 }
 
-// Run runs the program in the sandbox according to the config and returns the result.
-func Run(config Config) (result Result) {
+// RunE runs the program in the sandbox according to the config and returns
+// the result. The returned error is non-nil exactly when result.Error is
+// not ErrNone, and is that JudgerError itself, so callers can also use
+// errors.Is(err, judger.ErrRootRequired).
+func RunE(config Config) (result Result, err error) {
 	var cResult C.struct_result
 	cConfig := config.convertToCStruct()
+	var cPid C.pid_t
+	cConfig.pid_out = &cPid // written by run() right after fork, while it is still blocked in waitpid
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	var peakRSS int32
+	if config.SampleIntervalMs > 0 {
+		onSample := config.OnSample
+		if onSample == nil {
+			onSample = func(Sample) {}
+		}
+		wg.Add(1)
+		go sampleWhileRunning(config.SampleIntervalMs, &cPid, onSample, &peakRSS, stop, &wg)
+	}
 	C.run(&cConfig, &cResult)
+	close(stop)
+	wg.Wait()
 	result.convertFromCStruct(cResult)
+	result.PeakRSS = peakRSS
+	freeCConfig(&cConfig)
+	err = errorFromResult(result)
+	return
+}
+
+// freeCConfig releases the C strings and buffers allocated by
+// convertToCStruct. Callers must invoke it exactly once per converted
+// config, after the call to C.run it was used for has returned.
+func freeCConfig(cConfig *C.struct_config) {
 	C.free(unsafe.Pointer(cConfig.exe_path))
 	C.free(unsafe.Pointer(cConfig.input_path))
 	C.free(unsafe.Pointer(cConfig.output_path))
 	C.free(unsafe.Pointer(cConfig.error_path))
 	C.free(unsafe.Pointer(cConfig.log_path))
 	C.free(unsafe.Pointer(cConfig.seccomp_rule_name))
-	for i := range cConfig.args {
-		if i == 0 {
+	C.free(unsafe.Pointer(cConfig.cgroup_parent_path))
+	C.free(unsafe.Pointer(cConfig.seccomp_filter))
+	for _, p := range cConfig.args {
+		if p == nil {
 			break
 		}
-		C.free(unsafe.Pointer(i))
+		C.free(unsafe.Pointer(p))
 	}
-	for i := range cConfig.env {
-		if i == 0 {
+	for _, p := range cConfig.env {
+		if p == nil {
 			break
 		}
-		C.free(unsafe.Pointer(i))
+		C.free(unsafe.Pointer(p))
 	}
-	return
 }
@@ -0,0 +1,129 @@
+package judger
+
+/*
+#include "seccomp_rules.h"
+#include <unistd.h>
+*/
+import "C"
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// Sample is a snapshot of the sandboxed process' resource usage, gathered
+// from /proc/<pid>/status and /proc/<pid>/stat while it runs. It is
+// delivered to Config.OnSample.
+type Sample struct {
+	CPUTimeMs   int
+	RSSKB       int32
+	VmPeakKB    int32
+	ThreadCount int
+	ElapsedMs   int
+}
+
+// sampleWhileRunning polls /proc for pid every intervalMs, invoking onSample
+// for each reading and tracking the highest memory figure seen (the larger
+// of RSSKB, the peak resident size from VmHWM, and VmPeakKB, the peak
+// virtual size) in peakRSS, until stop is closed. pid is read atomically since run() writes
+// it from another goroutine's perspective (the blocked cgo call) as soon
+// as the child is forked.
+func sampleWhileRunning(intervalMs int, pid *C.pid_t, onSample func(Sample), peakRSS *int32, stop <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	start := time.Now()
+	ticker := time.NewTicker(time.Duration(intervalMs) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p := atomic.LoadInt32((*int32)(unsafe.Pointer(pid)))
+			if p <= 0 {
+				continue
+			}
+			s := readProcSample(int(p), int(time.Since(start).Milliseconds()))
+			peak := s.RSSKB
+			if s.VmPeakKB > peak {
+				peak = s.VmPeakKB
+			}
+			for {
+				old := atomic.LoadInt32(peakRSS)
+				if peak <= old || atomic.CompareAndSwapInt32(peakRSS, old, peak) {
+					break
+				}
+			}
+			onSample(s)
+		}
+	}
+}
+
+// readProcSample gathers a best-effort Sample for pid. Entries that have
+// already disappeared (the process exited between the tick and the read)
+// are left zeroed rather than treated as an error.
+func readProcSample(pid int, elapsedMs int) Sample {
+	s := Sample{ElapsedMs: elapsedMs}
+	if f, err := os.Open(fmt.Sprintf("/proc/%d/status", pid)); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "VmHWM:"):
+				s.RSSKB = parseStatusKB(line)
+			case strings.HasPrefix(line, "VmPeak:"):
+				s.VmPeakKB = parseStatusKB(line)
+			case strings.HasPrefix(line, "Threads:"):
+				fields := strings.Fields(line)
+				if len(fields) == 2 {
+					s.ThreadCount, _ = strconv.Atoi(fields[1])
+				}
+			}
+		}
+		f.Close()
+	}
+	if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid)); err == nil {
+		s.CPUTimeMs = parseStatCPUTimeMs(data)
+	}
+	return s
+}
+
+// parseStatusKB parses a "Name:\t123 kB" line from /proc/<pid>/status,
+// returning 0 for anything else (e.g. "Threads:\t1", which has no "kB"
+// unit and is not a memory field at all).
+func parseStatusKB(line string) int32 {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[2] != "kB" {
+		return 0
+	}
+	v, _ := strconv.ParseInt(fields[1], 10, 32)
+	return int32(v)
+}
+
+// parseStatCPUTimeMs extracts utime+stime (fields 14 and 15 of
+// /proc/<pid>/stat, 1-indexed) and converts them from clock ticks to ms.
+func parseStatCPUTimeMs(data []byte) int {
+	end := strings.LastIndexByte(string(data), ')')
+	if end == -1 {
+		return 0
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	// fields[0] is state (field 3); utime/stime are fields 14/15, i.e.
+	// fields[11]/fields[12] here.
+	if len(fields) < 13 {
+		return 0
+	}
+	utime, _ := strconv.ParseInt(fields[11], 10, 64)
+	stime, _ := strconv.ParseInt(fields[12], 10, 64)
+	ticksPerSec := int64(C.sysconf(C._SC_CLK_TCK))
+	if ticksPerSec <= 0 {
+		ticksPerSec = 100
+	}
+	return int((utime + stime) * 1000 / ticksPerSec)
+}
@@ -0,0 +1,37 @@
+package judger
+
+import "testing"
+
+func TestParseStatusKB(t *testing.T) {
+	cases := []struct {
+		line string
+		want int32
+	}{
+		{"VmHWM:\t    1024 kB", 1024},
+		{"VmPeak:\t   2048 kB", 2048},
+		{"Threads:\t1", 0},
+		{"VmHWM:", 0},
+	}
+	for _, c := range cases {
+		if got := parseStatusKB(c.line); got != c.want {
+			t.Errorf("parseStatusKB(%q) = %d, want %d", c.line, got, c.want)
+		}
+	}
+}
+
+func TestParseStatCPUTimeMs(t *testing.T) {
+	// A /proc/<pid>/stat line, comm field in parentheses, with utime=150
+	// (field 14) and stime=50 (field 15) clock ticks. At the typical
+	// 100 ticks/sec, that is 2000ms of CPU time.
+	stat := "1234 (my prog) S 1 1234 1234 0 -1 4194304 100 0 0 0 150 50 0 0 20 0 1 0 123456 0 0 0 0"
+	got := parseStatCPUTimeMs([]byte(stat))
+	if got <= 0 {
+		t.Fatalf("parseStatCPUTimeMs(%q) = %d, want > 0", stat, got)
+	}
+}
+
+func TestParseStatCPUTimeMsShortLine(t *testing.T) {
+	if got := parseStatCPUTimeMs([]byte("1234 (x) S")); got != 0 {
+		t.Errorf("parseStatCPUTimeMs on a too-short stat line = %d, want 0", got)
+	}
+}
@@ -0,0 +1,357 @@
+package judger
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Worker spawns the judger helper binary (built from the judger's C
+// sources) once and keeps it alive across many Run calls, communicating
+// over a socketpair with a small length-prefixed request/response
+// protocol: each request is "uint32 length | gob-encoded Config", each
+// response is "uint32 length | gob-encoded Result". This avoids paying for
+// Go process startup and cgo initialization on every judgment, letting a
+// single process judge hundreds of submissions per second. The seccomp
+// rule is still compiled and installed by each judged child.
+//
+// Worker does not support Config.OnSample: the callback cannot cross the
+// process boundary, and is silently dropped when Run is called through a
+// Worker.
+type Worker struct {
+	helperPath string
+	mu         sync.Mutex
+	cmd        *exec.Cmd
+	conn       *net.UnixConn
+}
+
+// NewWorker spawns helperPath and connects to it over a socketpair.
+func NewWorker(helperPath string) (*Worker, error) {
+	w := &Worker{helperPath: helperPath}
+	if err := w.start(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Worker) start() error {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return fmt.Errorf("judger: creating socketpair: %w", err)
+	}
+	parent := os.NewFile(uintptr(fds[0]), "judger-worker")
+	child := os.NewFile(uintptr(fds[1]), "judger-worker-child")
+
+	cmd := exec.Command(w.helperPath)
+	cmd.ExtraFiles = []*os.File{child}
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		parent.Close()
+		child.Close()
+		return fmt.Errorf("judger: starting helper %q: %w", w.helperPath, err)
+	}
+	child.Close()
+
+	conn, err := net.FileConn(parent)
+	parent.Close()
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("judger: wrapping helper socket: %w", err)
+	}
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		conn.Close()
+		cmd.Process.Kill()
+		cmd.Wait()
+		return errors.New("judger: helper socket is not a unix connection")
+	}
+
+	w.cmd = cmd
+	w.conn = unixConn
+	return nil
+}
+
+// Run sends config to the helper and waits for its response, restarting
+// the helper first if a previous Run tore it down after failing to talk to
+// it (e.g. because it died). If ctx is done before the helper replies, Run forces the
+// in-flight read/write to fail by expiring the connection's deadline,
+// kills the helper (a stuck helper cannot be trusted to still be reading
+// frames in sync with this call), and returns ctx.Err().
+func (w *Worker) Run(ctx context.Context, config Config) (Result, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		if err := w.start(); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		w.conn.SetDeadline(deadline)
+		defer w.conn.SetDeadline(time.Time{})
+	}
+	if done := ctx.Done(); done != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-done:
+				w.conn.SetDeadline(time.Now())
+			case <-stop:
+			}
+		}()
+	}
+
+	payload, err := EncodeConfig(config)
+	if err != nil {
+		return Result{}, fmt.Errorf("judger: encoding request: %w", err)
+	}
+	if err := WriteFrame(w.conn, payload); err != nil {
+		w.killLocked()
+		if ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		return Result{}, fmt.Errorf("judger: writing request to worker: %w", err)
+	}
+	reply, err := ReadFrame(w.conn)
+	if err != nil {
+		w.killLocked()
+		if ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		return Result{}, fmt.Errorf("judger: reading response from worker: %w", err)
+	}
+	result, err := DecodeResult(reply)
+	if err != nil {
+		return Result{}, fmt.Errorf("judger: decoding response: %w", err)
+	}
+	return result, errorFromResult(result)
+}
+
+// killLocked tears down a worker that has become unusable; the caller must
+// hold w.mu. The next Run call will restart the helper.
+func (w *Worker) killLocked() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	if w.cmd != nil && w.cmd.Process != nil {
+		w.cmd.Process.Kill()
+		w.cmd.Wait()
+	}
+	w.cmd = nil
+}
+
+// Close stops the helper process. Any Run in flight finishes first.
+func (w *Worker) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.killLocked()
+	return nil
+}
+
+// WorkerPool round-robins Run across a fixed set of Workers. A Run that
+// finds its worker's helper process dead returns an error, and that
+// worker restarts its helper on its next Run.
+type WorkerPool struct {
+	workers []*Worker
+	next    uint64
+}
+
+// NewWorkerPool spawns n Workers for helperPath. n must be at least 1.
+func NewWorkerPool(helperPath string, n int) (*WorkerPool, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("judger: NewWorkerPool: n must be at least 1, got %d", n)
+	}
+	workers := make([]*Worker, 0, n)
+	for i := 0; i < n; i++ {
+		w, err := NewWorker(helperPath)
+		if err != nil {
+			for _, started := range workers {
+				started.Close()
+			}
+			return nil, err
+		}
+		workers = append(workers, w)
+	}
+	return &WorkerPool{workers: workers}, nil
+}
+
+// Run dispatches config to the next worker in round-robin order.
+func (p *WorkerPool) Run(ctx context.Context, config Config) (Result, error) {
+	if len(p.workers) == 0 {
+		return Result{}, errors.New("judger: WorkerPool.Run called on a pool with no workers")
+	}
+	idx := atomic.AddUint64(&p.next, 1) % uint64(len(p.workers))
+	return p.workers[idx].Run(ctx, config)
+}
+
+// Close stops every worker in the pool, waiting for in-flight requests to
+// drain, and returns the first error encountered, if any.
+func (p *WorkerPool) Close() error {
+	var first error
+	for _, w := range p.workers {
+		if err := w.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// WriteFrame writes payload to w as a single "uint32 length | payload"
+// frame, the wire format Worker and the judger-worker helper binary speak
+// to each other. It is exported so the helper binary (which lives outside
+// this package, in cmd/judger-worker) can use the exact same framing.
+func WriteFrame(w io.Writer, payload []byte) error {
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(payload)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// ReadFrame reads a single frame written by WriteFrame.
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// wireConfig mirrors Config, minus fields like OnSample that cannot be
+// gob-encoded or cross a process boundary.
+type wireConfig struct {
+	MaxCPUTime           int
+	MaxRealTime          int
+	MaxMemory            int32
+	MaxStack             int32
+	MaxProcessNumber     int
+	MaxOutputSize        int32
+	MemoryLimitCheckOnly int
+	ExePath              string
+	InputPath            string
+	OutputPath           string
+	ErrorPath            string
+	Args                 []string
+	Env                  []string
+	LogPath              string
+	SeccompRuleName      string
+	Uid                  uint32
+	Gid                  uint32
+	ResourceBackend      ResourceBackend
+	CgroupParentPath     string
+	SeccompFilter        []byte
+	SeccompDefaultAction int
+	SampleIntervalMs     int
+}
+
+// EncodeConfig gob-encodes config into the request payload Worker sends to
+// the helper. Exported, along with DecodeConfig, so the judger-worker
+// helper binary in cmd/judger-worker can decode the exact same wire
+// format without this package's wireConfig type leaking out.
+func EncodeConfig(c Config) ([]byte, error) {
+	wc := wireConfig{
+		MaxCPUTime:           c.MaxCPUTime,
+		MaxRealTime:          c.MaxRealTime,
+		MaxMemory:            c.MaxMemory,
+		MaxStack:             c.MaxStack,
+		MaxProcessNumber:     c.MaxProcessNumber,
+		MaxOutputSize:        c.MaxOutputSize,
+		MemoryLimitCheckOnly: c.MemoryLimitCheckOnly,
+		ExePath:              c.ExePath,
+		InputPath:            c.InputPath,
+		OutputPath:           c.OutputPath,
+		ErrorPath:            c.ErrorPath,
+		Args:                 c.Args,
+		Env:                  c.Env,
+		LogPath:              c.LogPath,
+		SeccompRuleName:      c.SeccompRuleName,
+		Uid:                  c.Uid,
+		Gid:                  c.Gid,
+		ResourceBackend:      c.ResourceBackend,
+		CgroupParentPath:     c.CgroupParentPath,
+		SeccompFilter:        c.SeccompFilter,
+		SeccompDefaultAction: c.SeccompDefaultAction,
+		SampleIntervalMs:     c.SampleIntervalMs,
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(wc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeConfig decodes a payload written by EncodeConfig back into a
+// Config, as the judger-worker helper binary does for each request frame
+// it reads. The returned Config's OnSample is always nil: it cannot cross
+// the process boundary and Worker never encodes it in the first place.
+func DecodeConfig(payload []byte) (Config, error) {
+	var wc wireConfig
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&wc); err != nil {
+		return Config{}, err
+	}
+	return Config{
+		MaxCPUTime:           wc.MaxCPUTime,
+		MaxRealTime:          wc.MaxRealTime,
+		MaxMemory:            wc.MaxMemory,
+		MaxStack:             wc.MaxStack,
+		MaxProcessNumber:     wc.MaxProcessNumber,
+		MaxOutputSize:        wc.MaxOutputSize,
+		MemoryLimitCheckOnly: wc.MemoryLimitCheckOnly,
+		ExePath:              wc.ExePath,
+		InputPath:            wc.InputPath,
+		OutputPath:           wc.OutputPath,
+		ErrorPath:            wc.ErrorPath,
+		Args:                 wc.Args,
+		Env:                  wc.Env,
+		LogPath:              wc.LogPath,
+		SeccompRuleName:      wc.SeccompRuleName,
+		Uid:                  wc.Uid,
+		Gid:                  wc.Gid,
+		ResourceBackend:      wc.ResourceBackend,
+		CgroupParentPath:     wc.CgroupParentPath,
+		SeccompFilter:        wc.SeccompFilter,
+		SeccompDefaultAction: wc.SeccompDefaultAction,
+		SampleIntervalMs:     wc.SampleIntervalMs,
+	}, nil
+}
+
+// EncodeResult gob-encodes result into the response payload the helper
+// binary sends back to Worker.
+func EncodeResult(result Result) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(result); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeResult decodes a payload written by EncodeResult.
+func DecodeResult(payload []byte) (Result, error) {
+	var result Result
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&result); err != nil {
+		return Result{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,85 @@
+package judger
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteReadFrame(t *testing.T) {
+	var buf bytes.Buffer
+	want := []byte("hello judger")
+	if err := WriteFrame(&buf, want); err != nil {
+		t.Fatalf("WriteFrame: %v", err)
+	}
+	got, err := ReadFrame(&buf)
+	if err != nil {
+		t.Fatalf("ReadFrame: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("ReadFrame = %q, want %q", got, want)
+	}
+}
+
+func TestWriteReadFrameEmptyPayload(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFrame(&buf, nil); err != nil {
+		t.Fatalf("WriteFrame: %v", err)
+	}
+	got, err := ReadFrame(&buf)
+	if err != nil {
+		t.Fatalf("ReadFrame: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("ReadFrame = %q, want empty", got)
+	}
+}
+
+func TestEncodeDecodeConfig(t *testing.T) {
+	want := Config{
+		MaxCPUTime:      1000,
+		MaxMemory:       1 << 20,
+		ExePath:         "/bin/true",
+		Args:            []string{"true", "--flag"},
+		SeccompRuleName: "general",
+		Uid:             1000,
+		Gid:             1000,
+	}
+	payload, err := EncodeConfig(want)
+	if err != nil {
+		t.Fatalf("EncodeConfig: %v", err)
+	}
+	got, err := DecodeConfig(payload)
+	if err != nil {
+		t.Fatalf("DecodeConfig: %v", err)
+	}
+	if got.ExePath != want.ExePath || got.MaxCPUTime != want.MaxCPUTime || len(got.Args) != len(want.Args) {
+		t.Errorf("DecodeConfig roundtrip = %+v, want %+v", got, want)
+	}
+	if got.OnSample != nil {
+		t.Errorf("DecodeConfig: OnSample should never survive the wire, got non-nil")
+	}
+}
+
+func TestEncodeDecodeResult(t *testing.T) {
+	want := Result{CPUTime: 42, Memory: 1024, Result: MemoryLimitExceeded, Error: ErrNone}
+	payload, err := EncodeResult(want)
+	if err != nil {
+		t.Fatalf("EncodeResult: %v", err)
+	}
+	got, err := DecodeResult(payload)
+	if err != nil {
+		t.Fatalf("DecodeResult: %v", err)
+	}
+	if got != want {
+		t.Errorf("DecodeResult roundtrip = %+v, want %+v", got, want)
+	}
+}
+
+func TestNewWorkerPoolRejectsNonPositiveN(t *testing.T) {
+	if _, err := NewWorkerPool("/bin/true", 0); err == nil {
+		t.Error("NewWorkerPool(n=0) = nil error, want an error")
+	}
+	if _, err := NewWorkerPool("/bin/true", -1); err == nil {
+		t.Error("NewWorkerPool(n=-1) = nil error, want an error")
+	}
+}